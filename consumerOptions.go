@@ -36,6 +36,10 @@ type (
 		enabled bool
 		errs    *consumerErrorList
 	}
+
+	rebalanceStrategy struct {
+		strategy consumer.RebalanceStrategy
+	}
 )
 
 // PartialConstructionError returns a list of topics that could not be consumed as a list of ConsumerError.
@@ -64,6 +68,20 @@ func (p *partialConstruction) apply(opt *consumer.Options) {
 	opt.PartialConstruction = p.enabled
 }
 
+// WithRebalanceStrategy selects the partition-assignment protocol the consumer group advertises
+// during JoinGroup. Defaults to consumer.RebalanceStrategyEager, which revokes every partition a
+// member owns on each rebalance. consumer.RebalanceStrategyCooperativeSticky instead revokes only
+// the partitions a member is losing, substantially reducing pause time on group membership changes.
+func WithRebalanceStrategy(strategy consumer.RebalanceStrategy) ConsumerOption {
+	return &rebalanceStrategy{
+		strategy: strategy,
+	}
+}
+
+func (r *rebalanceStrategy) apply(opt *consumer.Options) {
+	opt.RebalanceStrategy = r.strategy
+}
+
 func (c consumerBuildOptions) addPartialConstructionError(errs *consumerErrorList) {
 	for _, opt := range c {
 		pe, ok := opt.(*partialConstruction)
@@ -71,4 +89,4 @@ func (c consumerBuildOptions) addPartialConstructionError(errs *consumerErrorLis
 			pe.errs = errs
 		}
 	}
-}
\ No newline at end of file
+}
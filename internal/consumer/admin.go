@@ -0,0 +1,248 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/uber-go/kafka-client/kafka/admin"
+)
+
+// DescribeCluster returns controller, broker, and rack metadata for cluster, reusing the
+// Sarama client MultiClusterConsumer already holds open for it.
+func (c *MultiClusterConsumer) DescribeCluster(cluster string) (*admin.ClusterMetadata, error) {
+	saramaClient, ok := c.clusterToSaramaClientMap[cluster]
+	if !ok {
+		return nil, fmt.Errorf("no sarama client for cluster %s", cluster)
+	}
+
+	resC := make(chan describeClusterResult, 1)
+	go func() {
+		controller, err := saramaClient.Controller()
+		if err != nil {
+			resC <- describeClusterResult{err: fmt.Errorf("describe cluster %s: %v", cluster, err)}
+			return
+		}
+		brokers := saramaClient.Brokers()
+		meta := &admin.ClusterMetadata{
+			ControllerID: controller.ID(),
+			Brokers:      make([]admin.Broker, 0, len(brokers)),
+		}
+		for _, broker := range brokers {
+			meta.Brokers = append(meta.Brokers, admin.Broker{
+				ID:   broker.ID(),
+				Addr: broker.Addr(),
+				Rack: broker.Rack(),
+			})
+		}
+		resC <- describeClusterResult{meta: meta}
+	}()
+
+	select {
+	case res := <-resC:
+		return res.meta, res.err
+	case <-adminTimeoutC(c.options.AdminRequestTimeout):
+		return nil, fmt.Errorf("describe cluster %s timed out after %v", cluster, c.options.AdminRequestTimeout)
+	}
+}
+
+type describeClusterResult struct {
+	meta *admin.ClusterMetadata
+	err  error
+}
+
+// adminTimeoutC returns a channel that fires after timeout, or nil (which blocks forever,
+// disabling the bound) when timeout is non-positive.
+func adminTimeoutC(timeout time.Duration) <-chan time.Time {
+	if timeout <= 0 {
+		return nil
+	}
+	return time.After(timeout)
+}
+
+// DescribeTopics returns partition count, leader, and ISR for each of topics on cluster.
+// Errors for individual topics are aggregated rather than failing the whole call.
+func (c *MultiClusterConsumer) DescribeTopics(cluster string, topics []string) ([]admin.TopicMetadata, error) {
+	saramaClient, ok := c.clusterToSaramaClientMap[cluster]
+	if !ok {
+		return nil, fmt.Errorf("no sarama client for cluster %s", cluster)
+	}
+
+	resC := make(chan describeTopicsResult, 1)
+	go func() {
+		result := make([]admin.TopicMetadata, 0, len(topics))
+		errList := make([]string, 0, len(topics))
+		for _, topic := range topics {
+			partitions, err := saramaClient.Partitions(topic)
+			if err != nil {
+				errList = append(errList, fmt.Sprintf("topic=%s err=%s", topic, err))
+				continue
+			}
+			topicMeta := admin.TopicMetadata{
+				Name:       topic,
+				Partitions: make([]admin.PartitionMetadata, 0, len(partitions)),
+			}
+			for _, partition := range partitions {
+				leader, err := saramaClient.Leader(topic, partition)
+				if err != nil {
+					errList = append(errList, fmt.Sprintf("topic=%s partition=%d err=%s", topic, partition, err))
+					continue
+				}
+				replicas, err := saramaClient.Replicas(topic, partition)
+				if err != nil {
+					errList = append(errList, fmt.Sprintf("topic=%s partition=%d err=%s", topic, partition, err))
+					continue
+				}
+				isr, err := saramaClient.InSyncReplicas(topic, partition)
+				if err != nil {
+					errList = append(errList, fmt.Sprintf("topic=%s partition=%d err=%s", topic, partition, err))
+					continue
+				}
+				topicMeta.Partitions = append(topicMeta.Partitions, admin.PartitionMetadata{
+					ID:       partition,
+					Leader:   leader.ID(),
+					Replicas: replicas,
+					ISR:      isr,
+				})
+			}
+			result = append(result, topicMeta)
+		}
+		var err error
+		if len(errList) > 0 {
+			err = fmt.Errorf("describe topics failed for %s", strings.Join(errList, ","))
+		}
+		resC <- describeTopicsResult{topics: result, err: err}
+	}()
+
+	select {
+	case res := <-resC:
+		return res.topics, res.err
+	case <-adminTimeoutC(c.options.AdminRequestTimeout):
+		return nil, fmt.Errorf("describe topics on cluster %s timed out after %v", cluster, c.options.AdminRequestTimeout)
+	}
+}
+
+type describeTopicsResult struct {
+	topics []admin.TopicMetadata
+	err    error
+}
+
+// DescribeConsumerGroup returns membership, assignment, and per-partition current/committed
+// offsets and lag for groupName on cluster.
+func (c *MultiClusterConsumer) DescribeConsumerGroup(groupName, cluster string) (*admin.ConsumerGroupMetadata, error) {
+	saramaClient, ok := c.clusterToSaramaClientMap[cluster]
+	if !ok {
+		return nil, fmt.Errorf("no sarama client for cluster %s", cluster)
+	}
+
+	resC := make(chan describeGroupResult, 1)
+	go func() {
+		// clusterAdmin wraps the cluster's shared saramaClient, which MultiClusterConsumer
+		// keeps open in clusterToSaramaClientMap and closes itself in Stop(). Closing the
+		// admin here would close that shared client out from under every other Describe*
+		// call and consumer on this cluster, so it is deliberately left open; the
+		// clusterAdmin wrapper itself is small and can simply be garbage collected.
+		clusterAdmin, err := sarama.NewClusterAdminFromClient(saramaClient)
+		if err != nil {
+			resC <- describeGroupResult{err: fmt.Errorf("describe consumer group %s: %v", groupName, err)}
+			return
+		}
+
+		groups, err := clusterAdmin.DescribeConsumerGroups([]string{groupName})
+		if err != nil || len(groups) == 0 {
+			resC <- describeGroupResult{err: fmt.Errorf("describe consumer group %s: %v", groupName, err)}
+			return
+		}
+		group := groups[0]
+
+		meta := &admin.ConsumerGroupMetadata{
+			GroupName: groupName,
+			State:     group.State,
+			Members:   make([]admin.GroupMember, 0, len(group.Members)),
+			Offsets:   make(map[string][]admin.PartitionOffsets),
+		}
+
+		topicPartitions := make(map[string][]int32)
+		for memberID, member := range group.Members {
+			assignment, err := member.GetMemberAssignment()
+			groupMember := admin.GroupMember{
+				MemberID:   memberID,
+				ClientID:   member.ClientId,
+				ClientHost: member.ClientHost,
+			}
+			if err == nil && assignment != nil {
+				groupMember.Assignment = assignment.Topics
+				for topic, partitions := range assignment.Topics {
+					topicPartitions[topic] = append(topicPartitions[topic], partitions...)
+				}
+			}
+			meta.Members = append(meta.Members, groupMember)
+		}
+
+		offsetsResp, err := clusterAdmin.ListConsumerGroupOffsets(groupName, topicPartitions)
+		if err != nil {
+			resC <- describeGroupResult{meta: meta, err: fmt.Errorf("describe consumer group %s offsets: %v", groupName, err)}
+			return
+		}
+		for topic, partitions := range topicPartitions {
+			offsets := make([]admin.PartitionOffsets, 0, len(partitions))
+			for _, partition := range partitions {
+				currentOffset, err := saramaClient.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					continue
+				}
+				block := offsetsResp.GetBlock(topic, partition)
+				committedOffset := int64(-1)
+				if block != nil {
+					committedOffset = block.Offset
+				}
+				lag := currentOffset - committedOffset
+				if committedOffset < 0 {
+					lag = -1
+				}
+				offsets = append(offsets, admin.PartitionOffsets{
+					Partition:       partition,
+					CurrentOffset:   currentOffset,
+					CommittedOffset: committedOffset,
+					Lag:             lag,
+				})
+			}
+			meta.Offsets[topic] = offsets
+		}
+
+		resC <- describeGroupResult{meta: meta}
+	}()
+
+	select {
+	case res := <-resC:
+		return res.meta, res.err
+	case <-adminTimeoutC(c.options.AdminRequestTimeout):
+		return nil, fmt.Errorf("describe consumer group %s timed out after %v", groupName, c.options.AdminRequestTimeout)
+	}
+}
+
+type describeGroupResult struct {
+	meta *admin.ConsumerGroupMetadata
+	err  error
+}
@@ -0,0 +1,390 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/uber-go/kafka-client/internal/util"
+	"github.com/uber-go/kafka-client/kafka"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+type (
+	// ClusterConsumer consumes a set of topics on a single cluster on behalf of a single
+	// consumer group, via a single underlying sarama.ConsumerGroup. It implements
+	// sarama.ConsumerGroupHandler itself so that every rebalance -- whether triggered by a
+	// membership change or by AddTopic/RemoveTopic -- drives the same ownership-diffing
+	// callback lifecycle.
+	ClusterConsumer struct {
+		groupName         string
+		cluster           string
+		saramaClient      sarama.Client
+		rebalanceClient   sarama.Client
+		consumerGroup     sarama.ConsumerGroup
+		preferredProtocol string
+		options           *Options
+		scope             tally.Scope
+		logger            *zap.Logger
+		lifecycle         *util.RunLifecycle
+
+		ownership *partitionOwnership
+		listener  *RebalanceListener
+
+		msgC     chan kafka.Message
+		doneC    chan struct{}
+		runDoneC chan struct{}
+		stopC    chan struct{}
+
+		// assignedC is closed the first time Setup is called, i.e. once this member has an
+		// actual partition assignment from the group. Assignment() is a live snapshot that
+		// starts out empty, so callers that need to know the *first* assignment has landed --
+		// MultiClusterConsumer.waitUntilReady chief among them -- must wait on this before
+		// reading it, or they will observe an empty assignment from before Setup ever ran.
+		assignedC    chan struct{}
+		assignedOnce sync.Once
+
+		// mu guards topics and cancel: AddTopic/RemoveTopic mutate topics and then cancel the
+		// in-flight Consume call so run's next iteration picks up the new topic list.
+		mu     sync.Mutex
+		topics map[string]kafka.ConsumerTopic
+		cancel context.CancelFunc
+	}
+)
+
+// NewClusterConsumer returns a ClusterConsumer for groupName on cluster, consuming the given
+// topics (which must all belong to cluster). It builds its own sarama.Client for the consumer
+// group, cloned from saramaClient's config, so that options.RebalanceStrategy and
+// options.InitialOffset actually govern this group's JoinGroup protocol registration and
+// fresh-partition start position; saramaClient itself is left untouched and still owned by
+// whoever constructed it.
+func NewClusterConsumer(
+	groupName string,
+	cluster string,
+	saramaClient sarama.Client,
+	topics kafka.ConsumerTopicList,
+	scope tally.Scope,
+	logger *zap.Logger,
+	options *Options,
+) (*ClusterConsumer, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+
+	preferredProtocol, err := NegotiateProtocol(map[string][]string{groupName: SupportedProtocols(options.RebalanceStrategy)})
+	if err != nil {
+		return nil, fmt.Errorf("cluster consumer could not negotiate a rebalance protocol for group %s on cluster %s: %v", groupName, cluster, err)
+	}
+
+	rebalanceConfig := *saramaClient.Config()
+	rebalanceConfig.Consumer.Group.Rebalance.GroupStrategies = balanceStrategies(SupportedProtocols(options.RebalanceStrategy))
+	rebalanceConfig.Consumer.Offsets.Initial = options.InitialOffset
+
+	rebalanceClient, err := sarama.NewClient(brokerAddrs(saramaClient), &rebalanceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cluster consumer could not create rebalance client for group %s on cluster %s: %v", groupName, cluster, err)
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(groupName, rebalanceClient)
+	if err != nil {
+		rebalanceClient.Close()
+		return nil, fmt.Errorf("cluster consumer could not create consumer group %s on cluster %s: %v", groupName, cluster, err)
+	}
+
+	topicMap := make(map[string]kafka.ConsumerTopic, len(topics))
+	for _, topic := range topics {
+		if topic.Pattern == nil {
+			topicMap[topic.Name] = topic
+		}
+	}
+
+	ownership := newPartitionOwnership()
+	return &ClusterConsumer{
+		groupName:         groupName,
+		cluster:           cluster,
+		saramaClient:      saramaClient,
+		rebalanceClient:   rebalanceClient,
+		consumerGroup:     consumerGroup,
+		preferredProtocol: preferredProtocol,
+		options:           options,
+		scope:             scope,
+		logger:            logger,
+		lifecycle:         util.NewRunLifecycle(groupName + "-" + cluster + "-consumer"),
+		ownership:         ownership,
+		listener:          newRebalanceListener(ownership),
+		msgC:              make(chan kafka.Message, options.RcvBufferSize),
+		doneC:             make(chan struct{}),
+		runDoneC:          make(chan struct{}),
+		stopC:             make(chan struct{}),
+		assignedC:         make(chan struct{}),
+		topics:            topicMap,
+	}, nil
+}
+
+// balanceStrategies maps protocol names, as returned by SupportedProtocols, to the
+// sarama.BalanceStrategy implementations that actually negotiate them during JoinGroup/SyncGroup.
+func balanceStrategies(protocols []string) []sarama.BalanceStrategy {
+	strategies := make([]sarama.BalanceStrategy, 0, len(protocols))
+	for _, protocol := range protocols {
+		switch protocol {
+		case string(RebalanceStrategyCooperativeSticky):
+			strategies = append(strategies, sarama.NewBalanceStrategyCooperativeSticky())
+		case "sticky":
+			strategies = append(strategies, sarama.NewBalanceStrategySticky())
+		case "roundrobin":
+			strategies = append(strategies, sarama.NewBalanceStrategyRoundRobin())
+		case "range":
+			strategies = append(strategies, sarama.NewBalanceStrategyRange())
+		}
+	}
+	return strategies
+}
+
+// brokerAddrs returns the addresses of every broker client already knows about, used to build a
+// second sarama.Client against the same cluster with a different sarama.Config.
+func brokerAddrs(client sarama.Client) []string {
+	brokers := client.Brokers()
+	addrs := make([]string, 0, len(brokers))
+	for _, broker := range brokers {
+		addrs = append(addrs, broker.Addr())
+	}
+	return addrs
+}
+
+// Name returns the consumer group name this ClusterConsumer belongs to.
+func (c *ClusterConsumer) Name() string {
+	return c.groupName
+}
+
+// Topics returns the topics currently being consumed, reflecting any AddTopic/RemoveTopic
+// calls made since construction.
+func (c *ClusterConsumer) Topics() kafka.ConsumerTopicList {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	topics := make(kafka.ConsumerTopicList, 0, len(c.topics))
+	for _, topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// topicNames returns the names of the topics currently being consumed.
+func (c *ClusterConsumer) topicNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.topics))
+	for name := range c.topics {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Start joins the consumer group and begins dispatching messages onto Messages().
+func (c *ClusterConsumer) Start() error {
+	return c.lifecycle.Start(func() error {
+		go c.run()
+		return nil
+	})
+}
+
+// run repeatedly calls sarama.ConsumerGroup.Consume, which blocks for the lifetime of one
+// generation and returns when the session ends -- whether because of a rebalance, a topic
+// list change requested through AddTopic/RemoveTopic, or Stop. It loops until stopC is closed.
+func (c *ClusterConsumer) run() {
+	defer close(c.runDoneC)
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.mu.Lock()
+		c.cancel = cancel
+		c.mu.Unlock()
+
+		if err := c.consumerGroup.Consume(ctx, c.topicNames(), c); err != nil && err != sarama.ErrClosedConsumerGroup {
+			c.logger.Error("cluster consumer session error", zap.String("groupName", c.groupName), zap.String("cluster", c.cluster), zap.Error(err))
+		}
+		cancel()
+
+		select {
+		case <-c.stopC:
+			return
+		default:
+		}
+	}
+}
+
+// AddTopic subscribes this ClusterConsumer to topic, rejoining the group with the updated
+// topic list. Safe to call while the consumer is running.
+func (c *ClusterConsumer) AddTopic(topic kafka.ConsumerTopic) error {
+	c.mu.Lock()
+	c.topics[topic.Name] = topic
+	cancel := c.cancel
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// RemoveTopic unsubscribes this ClusterConsumer from name, rejoining the group with the
+// updated topic list. Safe to call while the consumer is running.
+func (c *ClusterConsumer) RemoveTopic(name string) error {
+	c.mu.Lock()
+	delete(c.topics, name)
+	cancel := c.cancel
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// Assignment returns the topic/partitions currently owned by this ClusterConsumer, as of the
+// most recent Setup callback. Until the first Setup call this is always empty; callers that need
+// to distinguish "no assignment yet" from "assigned nothing" should wait on WaitForAssignment
+// first.
+func (c *ClusterConsumer) Assignment() map[string][]int32 {
+	return c.ownership.snapshot()
+}
+
+// WaitForAssignment blocks until Setup has been called at least once -- i.e. this member has
+// completed a JoinGroup/SyncGroup and has an assignment, even if that assignment is empty -- or
+// timeout elapses first, in which case it returns false.
+func (c *ClusterConsumer) WaitForAssignment(timeout time.Duration) bool {
+	select {
+	case <-c.assignedC:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stop leaves the consumer group and waits for the run loop to exit.
+func (c *ClusterConsumer) Stop() {
+	c.lifecycle.Stop(func() {
+		close(c.stopC)
+		c.mu.Lock()
+		cancel := c.cancel
+		c.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		<-c.runDoneC
+		if err := c.consumerGroup.Close(); err != nil {
+			c.logger.Error("cluster consumer failed to close consumer group", zap.String("groupName", c.groupName), zap.String("cluster", c.cluster), zap.Error(err))
+		}
+		// rebalanceClient is a dedicated client NewClusterConsumer created solely to carry this
+		// group's negotiated sarama.Config; unlike saramaClient, nothing else references it, so
+		// it is this ClusterConsumer's to close.
+		if err := c.rebalanceClient.Close(); err != nil {
+			c.logger.Error("cluster consumer failed to close rebalance client", zap.String("groupName", c.groupName), zap.String("cluster", c.cluster), zap.Error(err))
+		}
+		close(c.doneC)
+	})
+}
+
+// Closed returns a channel that is closed once Stop has finished tearing this consumer down.
+func (c *ClusterConsumer) Closed() <-chan struct{} {
+	return c.doneC
+}
+
+// Messages returns the channel this ClusterConsumer's claimed partitions are written to.
+func (c *ClusterConsumer) Messages() <-chan kafka.Message {
+	return c.msgC
+}
+
+// ResetOffset commits offsetRange.NewOffset for topic/partition, provided this ClusterConsumer
+// currently owns that partition.
+func (c *ClusterConsumer) ResetOffset(topic string, partition int32, offsetRange kafka.OffsetRange) error {
+	if !c.ownership.isOwned(TopicPartition{Topic: topic, Partition: partition}) {
+		return fmt.Errorf("cluster consumer does not own topic=%s partition=%d", topic, partition)
+	}
+
+	offsetManager, err := sarama.NewOffsetManagerFromClient(c.groupName, c.saramaClient)
+	if err != nil {
+		return fmt.Errorf("reset offset could not create offset manager for group %s: %v", c.groupName, err)
+	}
+	defer offsetManager.Close()
+
+	pom, err := offsetManager.ManagePartition(topic, partition)
+	if err != nil {
+		return fmt.Errorf("reset offset could not manage partition topic=%s partition=%d: %v", topic, partition, err)
+	}
+	defer pom.AsyncClose()
+
+	pom.MarkOffset(offsetRange.NewOffset, "")
+	return nil
+}
+
+// Setup is called by sarama at the start of every new session, with this member's assignment
+// for that session already known. It diffs the new assignment against the previous one and
+// drives RebalanceListener with exactly what was gained and lost, which is correct for both
+// eager rebalancing (session.Claims() is the member's entire assignment) and cooperative
+// rebalancing (session.Claims() already excludes partitions revoked earlier in the same
+// rebalance, so only the genuinely new partitions show up as added).
+func (c *ClusterConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	current := make(map[TopicPartition]struct{})
+	for topic, partitions := range session.Claims() {
+		for _, partition := range partitions {
+			current[TopicPartition{Topic: topic, Partition: partition}] = struct{}{}
+		}
+	}
+
+	added, lost := c.ownership.replace(current)
+	if len(lost) > 0 {
+		c.listener.OnPartitionsRevoked(lost)
+	}
+	if len(added) > 0 {
+		c.listener.OnPartitionsAssigned(added)
+	}
+	c.assignedOnce.Do(func() { close(c.assignedC) })
+	c.logger.Info("cluster consumer rebalanced", zap.String("groupName", c.groupName), zap.String("cluster", c.cluster), zap.String("protocol", c.preferredProtocol), zap.Int("added", len(added)), zap.Int("lost", len(lost)))
+	return nil
+}
+
+// Cleanup is called by sarama at the end of every session, once ConsumeClaim has returned for
+// every claim. Ownership is already kept current by Setup, so there is nothing left to do here.
+func (c *ClusterConsumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim forwards every message on claim onto c.msgC until the claim is exhausted
+// (session ending) or Stop is called.
+func (c *ClusterConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		select {
+		case c.msgC <- kafka.Message{
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		}:
+			session.MarkMessage(msg, "")
+		case <-c.stopC:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// fakeConsumerGroupSession is a minimal sarama.ConsumerGroupSession stub that only needs to
+// report Claims() for the tests below, which exercise Setup's ownership diffing and assignedC
+// signal without any real sarama connection.
+type fakeConsumerGroupSession struct {
+	claims map[string][]int32
+}
+
+func (f *fakeConsumerGroupSession) Claims() map[string][]int32 { return f.claims }
+func (f *fakeConsumerGroupSession) MemberID() string           { return "fake-member" }
+func (f *fakeConsumerGroupSession) GenerationID() int32        { return 0 }
+func (f *fakeConsumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (f *fakeConsumerGroupSession) Commit() {}
+func (f *fakeConsumerGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (f *fakeConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {}
+func (f *fakeConsumerGroupSession) Context() context.Context                                 { return context.Background() }
+
+func newTestClusterConsumer() *ClusterConsumer {
+	ownership := newPartitionOwnership()
+	return &ClusterConsumer{
+		groupName: "test-group",
+		cluster:   "test-cluster",
+		ownership: ownership,
+		listener:  newRebalanceListener(ownership),
+		assignedC: make(chan struct{}),
+		logger:    zap.NewNop(),
+	}
+}
+
+func TestClusterConsumerSetupSignalsAssignmentAndTracksOwnership(t *testing.T) {
+	cc := newTestClusterConsumer()
+
+	select {
+	case <-cc.assignedC:
+		t.Fatal("assignedC should not be closed before the first Setup call")
+	default:
+	}
+
+	if err := cc.Setup(&fakeConsumerGroupSession{claims: map[string][]int32{"t1": {0, 1}}}); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+
+	select {
+	case <-cc.assignedC:
+	default:
+		t.Fatal("assignedC should be closed after the first Setup call")
+	}
+
+	assignment := cc.Assignment()
+	if len(assignment["t1"]) != 2 {
+		t.Fatalf("expected 2 partitions assigned for t1, got %v", assignment)
+	}
+
+	if err := cc.Setup(&fakeConsumerGroupSession{claims: map[string][]int32{"t1": {1}}}); err != nil {
+		t.Fatalf("second Setup returned error: %v", err)
+	}
+	assignment = cc.Assignment()
+	if len(assignment["t1"]) != 1 || assignment["t1"][0] != 1 {
+		t.Fatalf("expected only partition 1 to remain assigned for t1, got %v", assignment)
+	}
+}
+
+func TestClusterConsumerWaitForAssignmentTimesOutWithoutSetup(t *testing.T) {
+	cc := newTestClusterConsumer()
+	if cc.WaitForAssignment(10 * time.Millisecond) {
+		t.Fatal("expected WaitForAssignment to time out when Setup has never been called")
+	}
+}
+
+func TestClusterConsumerWaitForAssignmentReturnsAfterSetup(t *testing.T) {
+	cc := newTestClusterConsumer()
+	if err := cc.Setup(&fakeConsumerGroupSession{claims: map[string][]int32{"t1": {0}}}); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if !cc.WaitForAssignment(time.Second) {
+		t.Fatal("expected WaitForAssignment to return true once Setup has run")
+	}
+}
@@ -21,7 +21,6 @@
 package consumer
 
 import (
-	"errors"
 	"testing"
 
 	"github.com/Shopify/sarama"
@@ -31,85 +30,75 @@ import (
 	"go.uber.org/zap"
 )
 
+// MultiClusterConsumerTestSuite covers the parts of MultiClusterConsumer that don't require a
+// live sarama connection: group/channel routing and the lookup failures ResetOffset/MergeDLQ
+// surface for a (group, cluster) pair nothing was ever registered for. Start/Stop and the
+// readiness gate drive real ClusterConsumers over an actual sarama.Client and are exercised in
+// integration tests instead.
 type MultiClusterConsumerTestSuite struct {
 	suite.Suite
 	consumer *MultiClusterConsumer
-	config   *kafka.ConsumerConfig
-	topics   kafka.ConsumerTopicList
-	options  *Options
-	msgCh    chan kafka.Message
+}
+
+func TestMultiClusterConsumerSuite(t *testing.T) {
+	suite.Run(t, new(MultiClusterConsumerTestSuite))
 }
 
 func (s *MultiClusterConsumerTestSuite) SetupTest() {
-	topic := kafka.ConsumerTopic{
-		Topic: kafka.Topic{
-			Name:       "unit-test",
-			Cluster:    "production-cluster",
-			BrokerList: nil,
+	s.consumer = NewMultiClusterConsumer(
+		kafka.ConsumerTopicList{},
+		map[string]map[string]*ClusterConsumer{
+			"group-a": {},
+			"group-b": {},
 		},
-		DLQ: kafka.Topic{
-			Name:       "unit-test-dlq",
-			Cluster:    "dlq-cluster",
-			BrokerList: nil,
-		},
-	}
-	s.topics = []kafka.ConsumerTopic{topic}
-	s.config = &kafka.ConsumerConfig{
-		TopicList:   s.topics,
-		GroupName:   "unit-test-cg",
-		Concurrency: 4,
-	}
-	s.options = testConsumerOptions()
-	s.msgCh = make(chan kafka.Message)
-	s.consumer, _ = NewMultiClusterConsumer(
-		s.config,
-		s.topics,
-		make(map[string]kafka.Consumer),
-		make(map[string]SaramaConsumer),
-		make(map[string]sarama.SyncProducer),
-		s.msgCh,
+		map[string]sarama.Client{},
+		make(chan kafka.Message, 1),
+		map[string]chan kafka.Message{"group-b": make(chan kafka.Message, 1)},
 		tally.NoopScope,
-		zap.L(),
+		zap.NewNop(),
+		nil,
 	)
 }
 
-func (s *MultiClusterConsumerTestSuite) TeardownTest() {
-	s.consumer.Stop()
+func (s *MultiClusterConsumerTestSuite) TestNameJoinsSortedGroupNames() {
+	s.Equal([]string{"group-a", "group-b"}, s.consumer.GroupNames())
+	s.Equal("group-a/group-b", s.consumer.Name())
 }
 
-func TestMultiClusterConsumerSuite(t *testing.T) {
-	suite.Run(t, new(MultiClusterConsumerTestSuite))
+func (s *MultiClusterConsumerTestSuite) TestMessagesReturnsSharedChannel() {
+	s.consumer.msgC <- kafka.Message{Topic: "t"}
+	msg := <-s.consumer.Messages()
+	s.Equal("t", msg.Topic)
 }
 
-func (s *MultiClusterConsumerTestSuite) TestStartSucceeds() {
-	cc1 := newMockConsumer("cc1", s.topics.TopicNames(), nil)
-	cc2 := newMockConsumer("cc2", s.topics.TopicNames(), nil)
-	s.consumer.clusterToConsumerMap["cc1"] = cc1
-	s.consumer.clusterToConsumerMap["cc2"] = cc2
-
-	s.NoError(s.consumer.Start())
+func (s *MultiClusterConsumerTestSuite) TestGroupMessagesPrefersDedicatedChannel() {
+	dedicated := s.consumer.groupMsgC["group-b"]
+	dedicated <- kafka.Message{Topic: "dedicated"}
+	msg := <-s.consumer.GroupMessages("group-b")
+	s.Equal("dedicated", msg.Topic)
+}
 
-	started, stopped := cc1.lifecycle.Status()
-	s.True(started)
-	s.False(stopped)
-	started, stopped = cc2.lifecycle.Status()
-	s.True(started)
-	s.False(stopped)
+func (s *MultiClusterConsumerTestSuite) TestGroupMessagesFallsBackToSharedChannel() {
+	s.consumer.msgC <- kafka.Message{Topic: "shared"}
+	msg := <-s.consumer.GroupMessages("group-a")
+	s.Equal("shared", msg.Topic)
 }
 
-func (s *MultiClusterConsumerTestSuite) TestStartConsumerCloseOnError() {
-	cc1 := newMockConsumer("cc1", s.topics.TopicNames(), nil)
-	cc2 := newMockConsumer("cc2", s.topics.TopicNames(), nil)
-	cc2.startErr = errors.New("error")
-	s.consumer.clusterToConsumerMap["cc1"] = cc1
-	s.consumer.clusterToConsumerMap["cc2"] = cc2
+func (s *MultiClusterConsumerTestSuite) TestResetOffsetUnknownGroupReturnsError() {
+	s.Error(s.consumer.ResetOffset("missing-group", "cluster", "topic", 0, kafka.OffsetRange{}))
+}
 
-	s.Error(s.consumer.Start())
+func (s *MultiClusterConsumerTestSuite) TestResetOffsetUnknownClusterReturnsError() {
+	s.Error(s.consumer.ResetOffset("group-a", "missing-cluster", "topic", 0, kafka.OffsetRange{}))
+}
 
-	started, stopped := cc1.lifecycle.Status()
-	s.True(stopped)
-	s.True(started)
-	started, stopped = cc2.lifecycle.Status()
-	s.True(stopped)
-	s.True(started)
-}
\ No newline at end of file
+func (s *MultiClusterConsumerTestSuite) TestMergeDLQAggregatesPerPartitionErrors() {
+	topic := kafka.ConsumerTopic{
+		Topic: kafka.Topic{Name: "t", Cluster: "c"},
+		DLQ:   kafka.Topic{Name: "t-dlq", Cluster: "missing-cluster"},
+	}
+	err := s.consumer.MergeDLQ("group-a", topic, map[int32]kafka.OffsetRange{0: {}, 1: {}})
+	s.Error(err)
+	s.Contains(err.Error(), "partition=0")
+	s.Contains(err.Error(), "partition=1")
+}
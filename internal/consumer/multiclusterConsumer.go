@@ -29,51 +29,92 @@ import (
 	"github.com/uber-go/kafka-client/kafka"
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type (
-	// MultiClusterConsumer is a map that contains multiple kafka consumers
+	// MultiClusterConsumer hosts one ClusterConsumer per (consumer group, cluster) pair, so
+	// the same topics can be subscribed to under several group IDs from a single process
+	// (e.g. a live-processing group alongside a shadow/replay group).
 	MultiClusterConsumer struct {
-		groupName                string
 		topics                   kafka.ConsumerTopicList
-		clusterConsumerMap       map[string]*ClusterConsumer
+		groupClusterConsumerMap  map[string]map[string]*ClusterConsumer
 		clusterToSaramaClientMap map[string]sarama.Client
 		msgC                     chan kafka.Message
+		groupMsgC                map[string]chan kafka.Message
 		doneC                    chan struct{}
 		scope                    tally.Scope
 		logger                   *zap.Logger
 		lifecycle                *util.RunLifecycle
+		options                  *Options
+
+		// mu guards groupClusterConsumerMap, serializing topicDiscoveries' callbacks, which
+		// add/remove discovered topics on its existing ClusterConsumers, with Start/Stop/ResetOffset.
+		mu               sync.Mutex
+		topicDiscoveries []*topicDiscovery
+
+		readyC chan struct{}
 	}
 )
 
-// NewMultiClusterConsumer returns a new consumer that consumes messages from
-// multiple Kafka clusters.
+// NewMultiClusterConsumer returns a new consumer that consumes messages from multiple Kafka
+// clusters on behalf of one or more consumer groups. groupClusterConsumerMap is keyed first by
+// group name and then by cluster name. groupMsgC optionally gives a group its own dedicated
+// message channel; a group absent from groupMsgC has its messages forwarded onto the shared msgC.
 func NewMultiClusterConsumer(
-	groupName string,
 	topics kafka.ConsumerTopicList,
-	clusterConsumerMap map[string]*ClusterConsumer,
+	groupClusterConsumerMap map[string]map[string]*ClusterConsumer,
 	saramaClients map[string]sarama.Client,
 	msgC chan kafka.Message,
+	groupMsgC map[string]chan kafka.Message,
 	scope tally.Scope,
 	logger *zap.Logger,
+	options *Options,
 ) *MultiClusterConsumer {
+	if options == nil {
+		options = DefaultOptions()
+	}
 	return &MultiClusterConsumer{
-		groupName:                groupName,
 		topics:                   topics,
-		clusterConsumerMap:       clusterConsumerMap,
+		groupClusterConsumerMap:  groupClusterConsumerMap,
 		clusterToSaramaClientMap: saramaClients,
 		msgC:      msgC,
+		groupMsgC: groupMsgC,
 		doneC:     make(chan struct{}),
 		scope:     scope,
 		logger:    logger,
-		lifecycle: util.NewRunLifecycle(groupName + "-consumer"),
+		lifecycle: util.NewRunLifecycle("multicluster-consumer"),
+		options:   options,
+		readyC:    make(chan struct{}),
 	}
 }
 
-// Name returns the consumer group name used by this consumer.
+// RebalanceStrategy returns the partition-assignment protocol this consumer's cluster
+// consumers advertise during JoinGroup. Each ClusterConsumer wires this into its own dedicated
+// sarama.Config when it is constructed.
+func (c *MultiClusterConsumer) RebalanceStrategy() RebalanceStrategy {
+	return c.options.RebalanceStrategy
+}
+
+// Name returns this consumer's name: its hosted group names, sorted and joined with "/". Use
+// GroupNames for the individual group names, e.g. to look up a group's dedicated channel.
 func (c *MultiClusterConsumer) Name() string {
-	return c.groupName
+	return strings.Join(c.GroupNames(), "/")
+}
+
+// GroupNames returns the set of consumer group names this consumer hosts, sorted.
+func (c *MultiClusterConsumer) GroupNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.groupClusterConsumerMap))
+	for groupName := range c.groupClusterConsumerMap {
+		names = append(names, groupName)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Topics returns a list of topics this consumer is consuming from.
@@ -81,68 +122,301 @@ func (c *MultiClusterConsumer) Topics() kafka.ConsumerTopicList {
 	return c.topics
 }
 
-// Start will fail to start if there is any clusterConsumer that fails.
+// Start will fail to start if there is any (group, cluster) consumer that fails, tearing down
+// everything that was already started. It then starts a topicDiscovery for every pattern-based
+// ConsumerTopic and a forwarding goroutine for every (group, cluster) pair, so messages begin
+// flowing onto Messages()/GroupMessages() once Start returns.
 func (c *MultiClusterConsumer) Start() error {
 	err := c.lifecycle.Start(func() (err error) {
-		for clusterName, consumer := range c.clusterConsumerMap {
-			if err = consumer.Start(); err != nil {
-				c.logger.With(
-					zap.Error(err),
-					zap.String("cluster", clusterName),
-				).Error("multicluster consumer start error")
-				return
+		c.mu.Lock()
+		for groupName, clusterConsumerMap := range c.groupClusterConsumerMap {
+			for clusterName, consumer := range clusterConsumerMap {
+				if err = consumer.Start(); err != nil {
+					c.logger.With(
+						zap.Error(err),
+						zap.String("groupName", groupName),
+						zap.String("cluster", clusterName),
+					).Error("multicluster consumer start error")
+					c.mu.Unlock()
+					return
+				}
+				go c.forwardMessages(groupName, clusterName, consumer)
 			}
 		}
+		// startTopicDiscoveries only builds each topicDiscovery and records it on
+		// c.topicDiscoveries while c.mu is held; discovery.start() itself is called after
+		// releasing c.mu below, since it synchronously runs the first refresh, which fans
+		// out through onPatternTopicsAdded/onPatternTopicsRemoved back into c.mu -- calling
+		// it while c.mu is still held would deadlock on the very first matching topic.
+		discoveries := c.startTopicDiscoveries()
+		c.mu.Unlock()
+
+		for _, discovery := range discoveries {
+			discovery.start()
+		}
 		return
 	})
 	if err != nil {
 		c.Stop()
 		return err
 	}
-	c.logger.Info("multicluster consumer started", zap.String("groupName", c.groupName), zap.Array("topicList", c.topics))
+	if err := c.waitUntilReady(); err != nil {
+		c.Stop()
+		return err
+	}
+	close(c.readyC)
+	c.logger.Info("multicluster consumer started", zap.Strings("groupNames", c.GroupNames()), zap.Array("topicList", c.topics))
 	c.scope.Counter(metrics.KafkaConsumerStarted).Inc(1)
 	return nil
 }
 
-// Stop will stop the consumer.
+// forwardMessages copies every message consumed by cc for (groupName, cluster) onto that
+// group's dedicated channel if one was configured via groupMsgC, otherwise onto the shared
+// msgC, until cc is closed or MultiClusterConsumer itself is stopped.
+func (c *MultiClusterConsumer) forwardMessages(groupName, cluster string, cc *ClusterConsumer) {
+	dest := c.groupDestination(groupName)
+	for {
+		select {
+		case msg, ok := <-cc.Messages():
+			if !ok {
+				return
+			}
+			select {
+			case dest <- msg:
+			case <-c.doneC:
+				return
+			}
+		case <-cc.Closed():
+			return
+		case <-c.doneC:
+			return
+		}
+	}
+}
+
+// Ready returns a channel that is closed once every (group, cluster) consumer's assigned
+// partitions have an initialized committed offset, per ConsumerGroupOffsetsChecker. If
+// c.options.ReadinessTimeout is zero, the channel is closed as soon as Start returns.
+func (c *MultiClusterConsumer) Ready() <-chan struct{} {
+	return c.readyC
+}
+
+// waitUntilReady blocks until ConsumerGroupOffsetsChecker confirms every (group, cluster)'s
+// assigned partitions are initialized, or c.options.ReadinessTimeout elapses first.
+func (c *MultiClusterConsumer) waitUntilReady() error {
+	if c.options.ReadinessTimeout <= 0 {
+		return nil
+	}
+
+	type groupCluster struct {
+		groupName string
+		cluster   string
+		cc        *ClusterConsumer
+	}
+
+	c.mu.Lock()
+	pairs := make([]groupCluster, 0, len(c.groupClusterConsumerMap))
+	for groupName, clusterConsumerMap := range c.groupClusterConsumerMap {
+		for cluster, cc := range clusterConsumerMap {
+			pairs = append(pairs, groupCluster{groupName: groupName, cluster: cluster, cc: cc})
+		}
+	}
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errC := make(chan error, len(pairs))
+	for _, pair := range pairs {
+		saramaClient, ok := c.clusterToSaramaClientMap[pair.cluster]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(pair groupCluster, saramaClient sarama.Client) {
+			defer wg.Done()
+			// pair.cc.Start() only spawns its run loop and returns; the group's first
+			// Setup/assignment hasn't necessarily landed yet, so Assignment() could still be
+			// empty here. Wait for it first, or a fresh consumer would be declared ready before
+			// it owns a single partition.
+			start := time.Now()
+			if !pair.cc.WaitForAssignment(c.options.ReadinessTimeout) {
+				errC <- fmt.Errorf("group=%s cluster=%s timed out after %v waiting for an initial partition assignment", pair.groupName, pair.cluster, c.options.ReadinessTimeout)
+				return
+			}
+			remaining := c.options.ReadinessTimeout - time.Since(start)
+			if remaining < 0 {
+				remaining = 0
+			}
+			checker := newConsumerGroupOffsetsChecker(pair.groupName, saramaClient, c.logger)
+			if err := checker.Check(pair.cc.Assignment(), remaining); err != nil {
+				errC <- fmt.Errorf("group=%s cluster=%s %v", pair.groupName, pair.cluster, err)
+			}
+		}(pair, saramaClient)
+	}
+	wg.Wait()
+	close(errC)
+
+	errs := make([]string, 0, len(errC))
+	for err := range errC {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("readiness check failed: %s", strings.Join(errs, ","))
+}
+
+// Stop will stop every (group, cluster) consumer.
 func (c *MultiClusterConsumer) Stop() {
 	c.lifecycle.Stop(func() {
-		for _, consumer := range c.clusterConsumerMap {
-			consumer.Stop()
+		for _, discovery := range c.topicDiscoveries {
+			discovery.stop()
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, clusterConsumerMap := range c.groupClusterConsumerMap {
+			for _, consumer := range clusterConsumerMap {
+				consumer.Stop()
+			}
 		}
 		for _, client := range c.clusterToSaramaClientMap {
 			client.Close()
 		}
 		close(c.doneC)
-		c.logger.Info("multicluster consumer stopped", zap.String("groupName", c.groupName), zap.Array("topicList", c.topics))
+		c.logger.Info("multicluster consumer stopped", zap.Array("topicList", c.topics))
 		c.scope.Counter(metrics.KafkaConsumerStopped).Inc(1)
 	})
 }
 
+// startTopicDiscoveries builds a topicDiscovery for every pattern-based ConsumerTopic, one per
+// cluster it subscribes from, and records each on c.topicDiscoveries so Stop can find them. It
+// returns the newly built discoveries; the caller starts each one after releasing c.mu, since
+// discovery.start() synchronously runs the first refresh, which can call back into
+// onPatternTopicsAdded/onPatternTopicsRemoved and re-acquire c.mu. Must be called with c.mu
+// held.
+func (c *MultiClusterConsumer) startTopicDiscoveries() []*topicDiscovery {
+	var discoveries []*topicDiscovery
+	for _, topic := range c.topics {
+		if topic.Pattern == nil {
+			continue
+		}
+		topic := topic
+		saramaClient, ok := c.clusterToSaramaClientMap[topic.Cluster]
+		if !ok {
+			c.logger.Error("no sarama client for pattern topic's cluster", zap.String("cluster", topic.Cluster))
+			continue
+		}
+		discovery := newTopicDiscovery(
+			topic.Cluster,
+			topic.Pattern,
+			c.options.TopicDiscoveryBlacklist,
+			c.options.TopicDiscoveryInterval,
+			saramaClient,
+			func(added []string) { c.onPatternTopicsAdded(topic, added) },
+			func(removed []string) { c.onPatternTopicsRemoved(topic.Cluster, removed) },
+			c.logger,
+		)
+		c.topicDiscoveries = append(c.topicDiscoveries, discovery)
+		discoveries = append(discoveries, discovery)
+	}
+	return discoveries
+}
+
+// onPatternTopicsAdded joins every group's cluster consumer for pattern's cluster to each
+// newly discovered topic name, so its messages start flowing onto that group's channel.
+func (c *MultiClusterConsumer) onPatternTopicsAdded(pattern kafka.ConsumerTopic, names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for groupName, clusterConsumerMap := range c.groupClusterConsumerMap {
+		cc, ok := clusterConsumerMap[pattern.Cluster]
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			concrete := pattern
+			concrete.Name = name
+			concrete.Pattern = nil
+			if err := cc.AddTopic(concrete); err != nil {
+				c.logger.Error("failed to add discovered topic", zap.String("groupName", groupName), zap.String("cluster", pattern.Cluster), zap.String("topic", name), zap.Error(err))
+				continue
+			}
+			c.logger.Info("added discovered topic", zap.String("groupName", groupName), zap.String("cluster", pattern.Cluster), zap.String("topic", name))
+		}
+	}
+}
+
+// onPatternTopicsRemoved leaves every topic name that stopped matching its pattern, on every
+// group's cluster consumer for cluster.
+func (c *MultiClusterConsumer) onPatternTopicsRemoved(cluster string, names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for groupName, clusterConsumerMap := range c.groupClusterConsumerMap {
+		cc, ok := clusterConsumerMap[cluster]
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			if err := cc.RemoveTopic(name); err != nil {
+				c.logger.Error("failed to remove discovered topic", zap.String("groupName", groupName), zap.String("cluster", cluster), zap.String("topic", name), zap.Error(err))
+				continue
+			}
+			c.logger.Info("removed discovered topic", zap.String("groupName", groupName), zap.String("cluster", cluster), zap.String("topic", name))
+		}
+	}
+}
+
 // Closed returns a channel that will be closed when the consumer is closed.
 func (c *MultiClusterConsumer) Closed() <-chan struct{} {
 	return c.doneC
 }
 
-// Messages returns a channel to receive messages on.
+// Messages returns this consumer's shared message channel. Groups configured with their own
+// dedicated channel via groupMsgC are not delivered here; use GroupMessages for those.
 func (c *MultiClusterConsumer) Messages() <-chan kafka.Message {
 	return c.msgC
 }
 
-// ResetOffset will reset the consumer offset for the specified cluster, topic, partition.
-func (c *MultiClusterConsumer) ResetOffset(cluster, topic string, partition int32, offsetRange kafka.OffsetRange) error {
-	cc, ok := c.clusterConsumerMap[cluster]
+// GroupMessages returns the channel carrying messages for groupName: its dedicated channel if
+// one was configured via groupMsgC, otherwise this consumer's shared channel.
+func (c *MultiClusterConsumer) GroupMessages(groupName string) <-chan kafka.Message {
+	return c.groupDestination(groupName)
+}
+
+// groupDestination is the write-side counterpart of GroupMessages, used by forwardMessages to
+// pick where a group's messages land.
+func (c *MultiClusterConsumer) groupDestination(groupName string) chan kafka.Message {
+	if ch, ok := c.groupMsgC[groupName]; ok {
+		return ch
+	}
+	return c.msgC
+}
+
+// clusterConsumer looks up the ClusterConsumer for (groupName, cluster), guarded by c.mu.
+func (c *MultiClusterConsumer) clusterConsumer(groupName, cluster string) (*ClusterConsumer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clusterConsumerMap, ok := c.groupClusterConsumerMap[groupName]
+	if !ok {
+		return nil, false
+	}
+	cc, ok := clusterConsumerMap[cluster]
+	return cc, ok
+}
+
+// ResetOffset will reset the consumer offset for the specified group, cluster, topic, partition.
+func (c *MultiClusterConsumer) ResetOffset(groupName, cluster, topic string, partition int32, offsetRange kafka.OffsetRange) error {
+	cc, ok := c.clusterConsumer(groupName, cluster)
 	if !ok {
 		return errors.New("no cluster consumer found")
 	}
 	return cc.ResetOffset(topic, partition, offsetRange)
 }
 
-// MergeDLQ will merge the offset range for each partition of the DLQ topic for the specified ConsumerTopic.
-func (c *MultiClusterConsumer) MergeDLQ(topic kafka.ConsumerTopic, offsetRanges map[int32]kafka.OffsetRange) error {
+// MergeDLQ will merge the offset range for each partition of the DLQ topic for the specified
+// group and ConsumerTopic.
+func (c *MultiClusterConsumer) MergeDLQ(groupName string, topic kafka.ConsumerTopic, offsetRanges map[int32]kafka.OffsetRange) error {
 	errList := make([]string, 0, 10)
 	for partition, offsetRange := range offsetRanges {
-		if err := c.ResetOffset(topic.DLQ.Cluster, topic.DLQ.Name, partition, offsetRange); err != nil {
+		if err := c.ResetOffset(groupName, topic.DLQ.Cluster, topic.DLQ.Name, partition, offsetRange); err != nil {
 			errList = append(errList, fmt.Sprintf("partition=%d err=%s", partition, err))
 		}
 	}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+const defaultReadinessPollInterval = 100 * time.Millisecond
+
+type (
+	// ConsumerGroupOffsetsChecker polls __consumer_offsets, via the group's sarama cluster
+	// admin, until every assigned (topic, partition) has an actual broker-side committed
+	// offset. MultiClusterConsumer uses this to avoid declaring itself ready before a freshly
+	// joined group has really started committing, and could not yet resume from where it left
+	// off if the process restarted.
+	ConsumerGroupOffsetsChecker struct {
+		groupName    string
+		saramaClient sarama.Client
+		logger       *zap.Logger
+	}
+)
+
+// newConsumerGroupOffsetsChecker returns a checker for one cluster's consumer group.
+func newConsumerGroupOffsetsChecker(groupName string, saramaClient sarama.Client, logger *zap.Logger) *ConsumerGroupOffsetsChecker {
+	return &ConsumerGroupOffsetsChecker{
+		groupName:    groupName,
+		saramaClient: saramaClient,
+		logger:       logger,
+	}
+}
+
+// Check blocks until every (topic, partition) in assignment has a committed offset, or returns
+// an error once timeout elapses first.
+func (o *ConsumerGroupOffsetsChecker) Check(assignment map[string][]int32, timeout time.Duration) error {
+	// clusterAdmin wraps o.saramaClient, which the caller (MultiClusterConsumer) keeps open in
+	// clusterToSaramaClientMap and closes itself in Stop(). It is deliberately never closed
+	// here, for the same reason DescribeConsumerGroup doesn't close its own: doing so would
+	// close the client out from under every other consumer/Describe* call on this cluster.
+	clusterAdmin, err := sarama.NewClusterAdminFromClient(o.saramaClient)
+	if err != nil {
+		return fmt.Errorf("offsets checker could not create cluster admin for group %s: %v", o.groupName, err)
+	}
+
+	pending := make(map[string][]int32, len(assignment))
+	for topic, partitions := range assignment {
+		pending[topic] = append([]int32{}, partitions...)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for len(pending) > 0 {
+		resp, err := clusterAdmin.ListConsumerGroupOffsets(o.groupName, pending)
+		if err != nil {
+			o.logger.Debug("offsets checker could not list consumer group offsets yet", zap.String("groupName", o.groupName), zap.Error(err))
+		} else {
+			for topic, partitions := range pending {
+				remaining := partitions[:0]
+				for _, partition := range partitions {
+					if !o.isInitialized(resp, topic, partition) {
+						remaining = append(remaining, partition)
+					}
+				}
+				if len(remaining) == 0 {
+					delete(pending, topic)
+				} else {
+					pending[topic] = remaining
+				}
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for group %s offsets to initialize for %v", timeout, o.groupName, pending)
+		}
+		time.Sleep(defaultReadinessPollInterval)
+	}
+	return nil
+}
+
+// isInitialized reports whether resp shows a real, broker-materialized committed offset for
+// partition, i.e. the group has actually committed to __consumer_offsets for it. A partition
+// nothing has ever committed for comes back with offset -1, regardless of the consumer's local
+// initial-offset policy.
+func (o *ConsumerGroupOffsetsChecker) isInitialized(resp *sarama.OffsetFetchResponse, topic string, partition int32) bool {
+	block := resp.GetBlock(topic, partition)
+	return block != nil && block.Offset >= 0
+}
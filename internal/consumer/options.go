@@ -0,0 +1,105 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+type (
+	// RebalanceStrategy controls which partition-assignment protocol a consumer group
+	// advertises to the broker during JoinGroup.
+	RebalanceStrategy string
+
+	// Options are the configurable knobs shared by ClusterConsumer and MultiClusterConsumer.
+	Options struct {
+		RcvBufferSize           int
+		ConcurrencyPerPartition int
+		OffsetCommitInterval    time.Duration
+		RebalanceDwellTime      time.Duration
+		MaxProcessingTime       time.Duration
+		Concurrency             int
+
+		// PartialConstruction, if true, allows the client to be built from the subset of
+		// topics/clusters that could be connected to instead of failing construction outright.
+		PartialConstruction bool
+
+		// RebalanceStrategy selects the partition-assignment protocol the consumer group
+		// advertises during JoinGroup. Defaults to RebalanceStrategyEager.
+		RebalanceStrategy RebalanceStrategy
+
+		// TopicDiscoveryInterval is how often a pattern-based ConsumerTopic re-lists its
+		// cluster's topics to pick up newly provisioned or removed topics. Defaults to one minute.
+		TopicDiscoveryInterval time.Duration
+
+		// TopicDiscoveryBlacklist, if set, is consulted for every topic name that matches a
+		// ConsumerTopic's Pattern; topics for which it returns true are never subscribed to.
+		TopicDiscoveryBlacklist func(topic string) bool
+
+		// ReadinessTimeout bounds how long MultiClusterConsumer.Start waits for
+		// ConsumerGroupOffsetsChecker to confirm every assigned partition has an initialized
+		// offset before Start returns. Zero disables the readiness gate entirely.
+		ReadinessTimeout time.Duration
+
+		// AdminRequestTimeout bounds each DescribeCluster/DescribeTopics/DescribeConsumerGroup
+		// call. Zero disables the bound.
+		AdminRequestTimeout time.Duration
+
+		// InitialOffset is the position a partition starts from the first time a consumer
+		// group consumes it, i.e. before it has ever committed an offset: sarama.OffsetOldest
+		// to replay the full retained log, or sarama.OffsetNewest to start from the tail.
+		// ClusterConsumer feeds this directly into the sarama.Config of the dedicated client it
+		// builds for the group. Defaults to sarama.OffsetOldest.
+		InitialOffset int64
+	}
+)
+
+const (
+	// RebalanceStrategyEager is the default all-or-nothing rebalance protocol
+	// ("range/roundrobin/sticky"): on every rebalance, every member revokes all of its
+	// partitions and waits for a brand new assignment before it resumes consuming.
+	RebalanceStrategyEager RebalanceStrategy = "range/roundrobin/sticky"
+
+	// RebalanceStrategyCooperativeSticky is the incremental ("cooperative-sticky") rebalance
+	// protocol: a member only revokes the specific partitions it is losing, keeps consuming
+	// the partitions it retains across the rebalance, and is handed newly assigned partitions
+	// once the sync response completes.
+	RebalanceStrategyCooperativeSticky RebalanceStrategy = "cooperative-sticky"
+)
+
+// DefaultOptions returns an Options populated with this package's defaults.
+func DefaultOptions() *Options {
+	return &Options{
+		RcvBufferSize:           2000,
+		ConcurrencyPerPartition: 1,
+		OffsetCommitInterval:    time.Second,
+		RebalanceDwellTime:      time.Second,
+		MaxProcessingTime:       250 * time.Millisecond,
+		Concurrency:             4,
+		RebalanceStrategy:       RebalanceStrategyEager,
+		TopicDiscoveryInterval:  defaultTopicDiscoveryInterval,
+		ReadinessTimeout:        30 * time.Second,
+		AdminRequestTimeout:     10 * time.Second,
+		InitialOffset:           sarama.OffsetOldest,
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"errors"
+	"sync"
+)
+
+type (
+	// TopicPartition identifies one partition of one topic.
+	TopicPartition struct {
+		Topic     string
+		Partition int32
+	}
+
+	// partitionOwnership tracks the set of partitions a ClusterConsumer currently believes it
+	// owns, updated incrementally (only the partitions gained and lost are added and removed) by
+	// ClusterConsumer.Setup and its RebalanceListener rather than rebuilt on every rebalance.
+	// ClusterConsumer.ResetOffset and MultiClusterConsumer.MergeDLQ consult it so a member
+	// never resets the offset of a partition it no longer (or does not yet) own.
+	partitionOwnership struct {
+		mu    sync.Mutex
+		owned map[TopicPartition]struct{}
+	}
+
+	// RebalanceListener is the two-callback lifecycle ClusterConsumer drives on every
+	// rebalance: onPartitionsRevoked is called first with exactly the partitions this member
+	// is losing, then onPartitionsAssigned with exactly the partitions newly handed to it.
+	// ClusterConsumer.Setup diffs each session's assignment against the previous one in both
+	// eager and cooperative mode, so only the partitions that actually changed hands are
+	// passed; partitions the member keeps are never revoked and their offsets are left untouched.
+	RebalanceListener struct {
+		ownership *partitionOwnership
+	}
+)
+
+// protocolPrecedence orders the protocol names ClusterConsumer knows how to negotiate, most to
+// least preferred, used to pick the highest-common protocol advertised by every member in a
+// JoinGroup response.
+var protocolPrecedence = []string{
+	string(RebalanceStrategyCooperativeSticky),
+	"sticky",
+	"roundrobin",
+	"range",
+}
+
+// SupportedProtocols returns the protocol names a member registers with the group for strategy,
+// in registration order. Eager mode registers every legacy protocol name, in the order "range",
+// "roundrobin", "sticky" (the reverse of protocolPrecedence), so it stays interoperable with
+// members still running an older, eager-only client. Cooperative mode
+// registers both "cooperative-sticky" and "sticky": a mid-rollout group, where some members have
+// upgraded and some haven't, still has a protocol in common ("sticky") until every member has
+// switched over, at which point the group naturally settles on "cooperative-sticky" since every
+// member prefers it.
+func SupportedProtocols(strategy RebalanceStrategy) []string {
+	if strategy == RebalanceStrategyCooperativeSticky {
+		return []string{string(RebalanceStrategyCooperativeSticky), "sticky"}
+	}
+	return []string{"range", "roundrobin", "sticky"}
+}
+
+// NegotiateProtocol picks the highest-common protocol out of the protocols every member in
+// memberProtocols (keyed by member ID) advertised support for in its JoinGroup metadata. It
+// returns an error if no single protocol is common to every member.
+func NegotiateProtocol(memberProtocols map[string][]string) (string, error) {
+	if len(memberProtocols) == 0 {
+		return "", errors.New("no members to negotiate a rebalance protocol for")
+	}
+
+	supportCount := make(map[string]int, len(protocolPrecedence))
+	for _, protocols := range memberProtocols {
+		seen := make(map[string]bool, len(protocols))
+		for _, protocol := range protocols {
+			seen[protocol] = true
+		}
+		for protocol := range seen {
+			supportCount[protocol]++
+		}
+	}
+
+	for _, protocol := range protocolPrecedence {
+		if supportCount[protocol] == len(memberProtocols) {
+			return protocol, nil
+		}
+	}
+	return "", errors.New("no rebalance protocol is common to every group member")
+}
+
+// newPartitionOwnership returns an empty ownership tracker.
+func newPartitionOwnership() *partitionOwnership {
+	return &partitionOwnership{owned: make(map[TopicPartition]struct{})}
+}
+
+func (o *partitionOwnership) revoke(lost []TopicPartition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, tp := range lost {
+		delete(o.owned, tp)
+	}
+}
+
+func (o *partitionOwnership) assign(added []TopicPartition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, tp := range added {
+		o.owned[tp] = struct{}{}
+	}
+}
+
+// replace resets ownership to exactly current, returning the partitions newly gained and lost
+// relative to the previous snapshot.
+func (o *partitionOwnership) replace(current map[TopicPartition]struct{}) (added, lost []TopicPartition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for tp := range current {
+		if _, ok := o.owned[tp]; !ok {
+			added = append(added, tp)
+		}
+	}
+	for tp := range o.owned {
+		if _, ok := current[tp]; !ok {
+			lost = append(lost, tp)
+		}
+	}
+	for _, tp := range lost {
+		delete(o.owned, tp)
+	}
+	for _, tp := range added {
+		o.owned[tp] = struct{}{}
+	}
+	return added, lost
+}
+
+func (o *partitionOwnership) isOwned(tp TopicPartition) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.owned[tp]
+	return ok
+}
+
+// snapshot returns the currently owned partitions grouped by topic.
+func (o *partitionOwnership) snapshot() map[string][]int32 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	assignment := make(map[string][]int32, len(o.owned))
+	for tp := range o.owned {
+		assignment[tp.Topic] = append(assignment[tp.Topic], tp.Partition)
+	}
+	return assignment
+}
+
+// newRebalanceListener returns a RebalanceListener that keeps ownership up to date as
+// ClusterConsumer drives its callbacks.
+func newRebalanceListener(ownership *partitionOwnership) *RebalanceListener {
+	return &RebalanceListener{ownership: ownership}
+}
+
+// OnPartitionsRevoked updates the ownership map to drop exactly the partitions lost. It does
+// not touch offsets for any partition not in lost: cooperative mode keeps consuming those
+// across the rebalance, so there is nothing to preserve by resetting them.
+func (l *RebalanceListener) OnPartitionsRevoked(lost []TopicPartition) {
+	if l == nil {
+		return
+	}
+	l.ownership.revoke(lost)
+}
+
+// OnPartitionsAssigned updates the ownership map to add exactly the newly assigned
+// partitions, without touching the offsets of partitions the member already owned.
+func (l *RebalanceListener) OnPartitionsAssigned(added []TopicPartition) {
+	if l == nil {
+		return
+	}
+	l.ownership.assign(added)
+}
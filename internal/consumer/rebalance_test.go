@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import "testing"
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		name            string
+		memberProtocols map[string][]string
+		wantProtocol    string
+		wantErr         bool
+	}{
+		{
+			name: "all members support cooperative-sticky",
+			memberProtocols: map[string][]string{
+				"m1": {"cooperative-sticky"},
+				"m2": {"cooperative-sticky"},
+			},
+			wantProtocol: "cooperative-sticky",
+		},
+		{
+			name: "mixed support falls back to highest common eager protocol",
+			memberProtocols: map[string][]string{
+				"m1": {"cooperative-sticky", "range", "roundrobin", "sticky"},
+				"m2": {"range", "roundrobin", "sticky"},
+			},
+			wantProtocol: "sticky",
+		},
+		{
+			name: "no common protocol",
+			memberProtocols: map[string][]string{
+				"m1": {"cooperative-sticky"},
+				"m2": {"range"},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "no members",
+			memberProtocols: map[string][]string{},
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NegotiateProtocol(tt.memberProtocols)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got protocol %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.wantProtocol {
+				t.Fatalf("got protocol %q, want %q", got, tt.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestSupportedProtocols(t *testing.T) {
+	eager := SupportedProtocols(RebalanceStrategyEager)
+	if len(eager) == 0 {
+		t.Fatal("eager strategy should advertise at least one protocol")
+	}
+	for _, protocol := range eager {
+		if protocol == string(RebalanceStrategyCooperativeSticky) {
+			t.Fatal("eager strategy must not advertise cooperative-sticky")
+		}
+	}
+
+	cooperative := SupportedProtocols(RebalanceStrategyCooperativeSticky)
+	if len(cooperative) != 2 || cooperative[0] != string(RebalanceStrategyCooperativeSticky) || cooperative[1] != "sticky" {
+		t.Fatalf("cooperative-sticky strategy should advertise itself and sticky, in that order, got %v", cooperative)
+	}
+}
+
+func TestPartitionOwnershipReplace(t *testing.T) {
+	o := newPartitionOwnership()
+
+	added, lost := o.replace(map[TopicPartition]struct{}{
+		{Topic: "t1", Partition: 0}: {},
+		{Topic: "t1", Partition: 1}: {},
+	})
+	if len(lost) != 0 {
+		t.Fatalf("first assignment should not lose anything, got %v", lost)
+	}
+	if len(added) != 2 {
+		t.Fatalf("expected 2 added partitions, got %v", added)
+	}
+	if !o.isOwned(TopicPartition{Topic: "t1", Partition: 0}) {
+		t.Fatal("expected t1/0 to be owned after replace")
+	}
+
+	added, lost = o.replace(map[TopicPartition]struct{}{
+		{Topic: "t1", Partition: 1}: {},
+		{Topic: "t2", Partition: 0}: {},
+	})
+	if len(added) != 1 || added[0] != (TopicPartition{Topic: "t2", Partition: 0}) {
+		t.Fatalf("expected only t2/0 added, got %v", added)
+	}
+	if len(lost) != 1 || lost[0] != (TopicPartition{Topic: "t1", Partition: 0}) {
+		t.Fatalf("expected only t1/0 lost, got %v", lost)
+	}
+	if o.isOwned(TopicPartition{Topic: "t1", Partition: 0}) {
+		t.Fatal("t1/0 should no longer be owned")
+	}
+	if !o.isOwned(TopicPartition{Topic: "t1", Partition: 1}) {
+		t.Fatal("t1/1 should remain owned across the rebalance")
+	}
+}
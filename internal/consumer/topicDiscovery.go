@@ -0,0 +1,152 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consumer
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+const defaultTopicDiscoveryInterval = time.Minute
+
+type (
+	// topicDiscovery periodically refreshes the broker metadata for one cluster and tells its
+	// owner which topics matching a pattern have newly appeared or disappeared. It does not
+	// start or stop consumers itself; MultiClusterConsumer owns that decision.
+	topicDiscovery struct {
+		cluster      string
+		pattern      *regexp.Regexp
+		blacklist    func(topic string) bool
+		interval     time.Duration
+		saramaClient sarama.Client
+		onAdded      func(topics []string)
+		onRemoved    func(topics []string)
+		logger       *zap.Logger
+
+		matched map[string]struct{}
+		stopC   chan struct{}
+		doneC   chan struct{}
+	}
+)
+
+// newTopicDiscovery constructs a topicDiscovery for a single (cluster, pattern) pair.
+// onAdded/onRemoved are invoked, from the discovery goroutine, with the set of topic names
+// that newly started or stopped matching pattern since the previous refresh.
+func newTopicDiscovery(
+	cluster string,
+	pattern *regexp.Regexp,
+	blacklist func(topic string) bool,
+	interval time.Duration,
+	saramaClient sarama.Client,
+	onAdded func(topics []string),
+	onRemoved func(topics []string),
+	logger *zap.Logger,
+) *topicDiscovery {
+	if interval <= 0 {
+		interval = defaultTopicDiscoveryInterval
+	}
+	if blacklist == nil {
+		blacklist = func(string) bool { return false }
+	}
+	return &topicDiscovery{
+		cluster:      cluster,
+		pattern:      pattern,
+		blacklist:    blacklist,
+		interval:     interval,
+		saramaClient: saramaClient,
+		onAdded:      onAdded,
+		onRemoved:    onRemoved,
+		logger:       logger,
+		matched:      make(map[string]struct{}),
+		stopC:        make(chan struct{}),
+		doneC:        make(chan struct{}),
+	}
+}
+
+// start runs the refresh loop in a new goroutine and returns immediately after the first
+// refresh has completed, so the initial set of matched topics is available to the caller.
+func (d *topicDiscovery) start() {
+	d.refresh()
+	go func() {
+		defer close(d.doneC)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.refresh()
+			case <-d.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// stop terminates the refresh loop and waits for it to exit.
+func (d *topicDiscovery) stop() {
+	close(d.stopC)
+	<-d.doneC
+}
+
+// refresh asks the cluster's Sarama client for the current topic list, matches it against
+// pattern, and reports the delta against the previously matched set via onAdded/onRemoved.
+func (d *topicDiscovery) refresh() {
+	if err := d.saramaClient.RefreshMetadata(); err != nil {
+		d.logger.Error("topic discovery failed to refresh metadata", zap.String("cluster", d.cluster), zap.Error(err))
+		return
+	}
+	topics, err := d.saramaClient.Topics()
+	if err != nil {
+		d.logger.Error("topic discovery failed to list topics", zap.String("cluster", d.cluster), zap.Error(err))
+		return
+	}
+
+	current := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		if !d.pattern.MatchString(topic) || d.blacklist(topic) {
+			continue
+		}
+		current[topic] = struct{}{}
+	}
+
+	var added, removed []string
+	for topic := range current {
+		if _, ok := d.matched[topic]; !ok {
+			added = append(added, topic)
+		}
+	}
+	for topic := range d.matched {
+		if _, ok := current[topic]; !ok {
+			removed = append(removed, topic)
+		}
+	}
+	d.matched = current
+
+	if len(added) > 0 {
+		d.onAdded(added)
+	}
+	if len(removed) > 0 {
+		d.onRemoved(removed)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package admin contains structured result types for introspecting a cluster, its topics, and
+// its consumer groups, as returned by MultiClusterConsumer's Describe* methods.
+package admin
+
+type (
+	// Broker describes one broker in a cluster's metadata.
+	Broker struct {
+		ID   int32
+		Addr string
+		Rack string
+	}
+
+	// ClusterMetadata describes a single Kafka cluster.
+	ClusterMetadata struct {
+		ControllerID int32
+		Brokers      []Broker
+	}
+
+	// PartitionMetadata describes one partition of a topic.
+	PartitionMetadata struct {
+		ID       int32
+		Leader   int32
+		Replicas []int32
+		ISR      []int32
+	}
+
+	// TopicMetadata describes one topic's partition layout.
+	TopicMetadata struct {
+		Name       string
+		Partitions []PartitionMetadata
+	}
+
+	// PartitionOffsets holds the current (log-end) and committed offsets for one partition,
+	// along with the derived consumer lag.
+	PartitionOffsets struct {
+		Partition       int32
+		CurrentOffset   int64
+		CommittedOffset int64
+		Lag             int64
+	}
+
+	// GroupMember describes one member of a consumer group and the partitions assigned to it.
+	GroupMember struct {
+		MemberID   string
+		ClientID   string
+		ClientHost string
+		Assignment map[string][]int32
+	}
+
+	// ConsumerGroupMetadata describes a consumer group's membership, assignment, and per-topic
+	// offsets/lag.
+	ConsumerGroupMetadata struct {
+		GroupName string
+		State     string
+		Members   []GroupMember
+		Offsets   map[string][]PartitionOffsets
+	}
+)
@@ -0,0 +1,73 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package kafka
+
+import (
+	"regexp"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// Topic identifies a single Kafka topic on a cluster.
+	Topic struct {
+		Name       string
+		Cluster    string
+		BrokerList []string
+	}
+
+	// ConsumerTopic is a topic to consume from, together with the topic its dead-letter-queue
+	// records are merged back in from. Exactly one of Name or Pattern should be set: Name
+	// consumes a single fixed topic, while Pattern subscribes to every topic on Cluster whose
+	// name matches it, re-evaluated periodically as topics are added or removed.
+	ConsumerTopic struct {
+		Topic
+		DLQ     Topic
+		Pattern *regexp.Regexp
+	}
+
+	// ConsumerTopicList is a list of ConsumerTopic.
+	ConsumerTopicList []ConsumerTopic
+)
+
+// TopicNames returns the fixed (non-pattern) topic names in this list.
+func (t ConsumerTopicList) TopicNames() []string {
+	names := make([]string, 0, len(t))
+	for _, topic := range t {
+		if topic.Pattern == nil {
+			names = append(names, topic.Name)
+		}
+	}
+	return names
+}
+
+// MarshalLogArray implements zapcore.ArrayMarshaler so a ConsumerTopicList can be logged
+// directly with zap.Array.
+func (t ConsumerTopicList) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, topic := range t {
+		if topic.Pattern != nil {
+			enc.AppendString(topic.Cluster + ":" + topic.Pattern.String())
+			continue
+		}
+		enc.AppendString(topic.Cluster + ":" + topic.Name)
+	}
+	return nil
+}